@@ -17,8 +17,10 @@ limitations under the License.
 package net
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"os"
 	"strconv"
 )
 
@@ -49,11 +51,42 @@ type LocalPort struct {
 	// Protocol is the protocol, "tcp" or "udp"
 	// The value is assumed to be lower-case
 	Protocol string
+	// Options carries extra socket-level options to apply before bind(), such
+	// as SO_REUSEADDR or IP_FREEBIND. A nil Options uses the platform default.
+	Options *SocketOptions
+}
+
+// SocketOptions are extra socket-level options that can be requested when
+// opening a LocalPort, wired through net.ListenConfig.Control. They are only
+// honored on Linux; on other platforms any non-nil SocketOptions (even one
+// with every option false) causes openLocalPort to fail rather than silently
+// ignore the request.
+type SocketOptions struct {
+	// ReuseAddr sets SO_REUSEADDR, allowing bind to a local address that's
+	// in TIME_WAIT state.
+	ReuseAddr bool
+	// ReusePort sets SO_REUSEPORT, allowing multiple sockets to bind the
+	// same address/port so traffic can be load-balanced between them, or a
+	// replacement listener started without a bind race.
+	ReusePort bool
+	// IPFreeBind sets IP_FREEBIND, allowing bind to an IP address that is
+	// not (yet) configured on the host, e.g. a service VIP.
+	IPFreeBind bool
+	// IPTransparent sets IP_TRANSPARENT, allowing bind to and accept
+	// connections for an IP address that isn't local, as used by
+	// transparent proxies.
+	IPTransparent bool
 }
 
 // NewLocalPort returns a LocalPort instance and ensures IPFamily and IP are
 // consistent and that the given protocol is valid
 func NewLocalPort(desc, ip string, ipFamily IPFamily, port int, protocol string) (*LocalPort, error) {
+	return NewLocalPortWithOptions(desc, ip, ipFamily, port, protocol, nil)
+}
+
+// NewLocalPortWithOptions behaves like NewLocalPort but additionally attaches
+// SocketOptions to apply when the returned LocalPort is opened.
+func NewLocalPortWithOptions(desc, ip string, ipFamily IPFamily, port int, protocol string, opts *SocketOptions) (*LocalPort, error) {
 	if protocol != "tcp" && protocol != "sctp" && protocol != "udp" {
 		return nil, fmt.Errorf("Unsupported protocol %s", protocol)
 	}
@@ -70,7 +103,27 @@ func NewLocalPort(desc, ip string, ipFamily IPFamily, port int, protocol string)
 			return nil, fmt.Errorf("ip address and family mismatch %s, %s", ip, ipFamily)
 		}
 	}
-	return &LocalPort{Description: desc, IP: ip, IPFamily: ipFamily, Port: port, Protocol: protocol}, nil
+	return &LocalPort{Description: desc, IP: ip, IPFamily: ipFamily, Port: port, Protocol: protocol, Options: opts}, nil
+}
+
+// NewLocalPortStrict behaves like NewLocalPort but, when strict is true,
+// additionally rejects an IPv4 or IPv6 ipFamily that SupportsIPv4 or
+// SupportsIPv6 report as unavailable on this host, so callers fail at
+// construction time instead of later at bind time.
+func NewLocalPortStrict(strict bool, desc, ip string, ipFamily IPFamily, port int, protocol string) (*LocalPort, error) {
+	lp, err := NewLocalPort(desc, ip, ipFamily, port, protocol)
+	if err != nil {
+		return nil, err
+	}
+	if strict {
+		if ipFamily == IPv4 && !SupportsIPv4() {
+			return nil, fmt.Errorf("IPv4 is not supported on this host")
+		}
+		if ipFamily == IPv6 && !SupportsIPv6() {
+			return nil, fmt.Errorf("IPv6 is not supported on this host")
+		}
+	}
+	return lp, nil
 }
 
 func (lp *LocalPort) String() string {
@@ -83,6 +136,25 @@ type Closeable interface {
 	Close() error
 }
 
+// FileCloseable is a Closeable that can also expose its underlying socket as
+// an *os.File, mirroring the stdlib's (*net.TCPListener).File and
+// (*net.UDPConn).File. The returned File is a dup of the socket, so callers
+// can hand it to a child process via exec.Cmd's ExtraFiles, letting the
+// reservation survive a fork/exec without a race where another process
+// grabs the port between this process closing it and the child re-binding.
+type FileCloseable interface {
+	Closeable
+	File() (*os.File, error)
+}
+
+// AsFileCloseable returns socket as a FileCloseable if it supports exposing
+// its underlying file descriptor, which is true of the Closeables returned
+// by openLocalPort for "tcp" and "udp".
+func AsFileCloseable(socket Closeable) (FileCloseable, bool) {
+	fc, ok := socket.(FileCloseable)
+	return fc, ok
+}
+
 // PortOpener can open a LocalPort and allows later closing it
 // Abstracted out for testing.
 type PortOpener interface {
@@ -99,32 +171,61 @@ func (l *listenPortOpener) OpenLocalPort(lp *LocalPort) (Closeable, error) {
 
 func openLocalPort(lp *LocalPort) (Closeable, error) {
 	var socket Closeable
-	network := lp.Protocol + string(lp.IPFamily)
+	network := lp.Protocol + string(preferredIPFamily(lp))
 	hostPort := net.JoinHostPort(lp.IP, strconv.Itoa(lp.Port))
+	lc, err := listenConfigForOptions(lp.Options)
+	if err != nil {
+		return nil, err
+	}
 	switch lp.Protocol {
 	case "tcp":
-		listener, err := net.Listen(network, hostPort)
+		listener, err := lc.Listen(context.Background(), network, hostPort)
 		if err != nil {
 			return nil, err
 		}
 		socket = listener
 	case "udp":
-		addr, err := net.ResolveUDPAddr(network, hostPort)
-		if err != nil {
-			return nil, err
-		}
-		conn, err := net.ListenUDP(network, addr)
+		conn, err := lc.ListenPacket(context.Background(), network, hostPort)
 		if err != nil {
 			return nil, err
 		}
-		socket = conn
+		socket = conn.(*net.UDPConn)
 	case "sctp":
-		// SCTP ports are intentionally ignored, to ensure we don't cause the sctp
-		// kernel module to be loaded, which breaks userspace SCTP support (and
-		// may be considered a security risk by some administrators).
-		return nil, nil
+		// openSCTPPort goes through sctp.ListenSCTP rather than
+		// net.ListenConfig, so it has no way to apply lc's Control callback.
+		// Reject rather than silently drop any requested SocketOptions.
+		if lp.Options != nil {
+			return nil, fmt.Errorf("SocketOptions are not supported for sctp")
+		}
+		return openSCTPPort(lp)
 	default:
 		return nil, fmt.Errorf("unknown protocol %q", lp.Protocol)
 	}
 	return socket, nil
 }
+
+// preferredIPFamily returns the IPFamily to bind lp with. If lp.IPFamily is
+// already set, or lp.IP pins a specific family, or lp's protocol is not tcp
+// or udp (sctp is opened by openSCTPPort, which uses lp.IPFamily directly),
+// it's returned unchanged. Otherwise, for an unbound "bind to all addresses"
+// tcp/udp LocalPort, it keeps the dual-stack "" when SupportsIPv4 reports
+// true, and picks "6" (tcp6/udp6) only when IPv4 is unavailable and
+// SupportsIPv6 reports true, so that an unsupported family isn't attempted
+// and surfaced as a late bind failure. It never picks "4".
+func preferredIPFamily(lp *LocalPort) IPFamily {
+	if lp.IPFamily != "" || lp.IP != "" {
+		return lp.IPFamily
+	}
+	switch lp.Protocol {
+	case "tcp", "udp":
+	default:
+		return lp.IPFamily
+	}
+	if SupportsIPv4() {
+		return ""
+	}
+	if SupportsIPv6() {
+		return IPv6
+	}
+	return ""
+}
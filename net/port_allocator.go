@@ -0,0 +1,235 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PortAllocatorMetrics holds counters describing a PortAllocator's activity,
+// useful for exporting as metrics.
+type PortAllocatorMetrics struct {
+	// OpenCount is the number of new underlying sockets the allocator kept.
+	// A socket opened by a call that lost a race with another call for the
+	// same LocalPort is closed again and not counted.
+	OpenCount int64
+	// BindFailures is the number of Open/Lease calls that failed to bind a
+	// new underlying socket.
+	BindFailures int64
+	// Contention is the number of Open/Lease calls that were satisfied by
+	// sharing an already-open socket instead of opening a new one.
+	Contention int64
+}
+
+// PortHandle is a reference-counted handle on a LocalPort obtained from a
+// PortAllocator. Release drops the caller's reference; a leased handle is
+// also released when its lease expires. Releasing a handle more than once
+// has no further effect.
+type PortHandle struct {
+	allocator *PortAllocator
+	key       string
+	socket    Closeable
+
+	mu         sync.Mutex
+	released   bool
+	timer      *time.Timer
+	generation int64
+}
+
+// Socket returns the underlying Closeable shared by every handle on this
+// reservation. It remains valid only until Release is called.
+func (h *PortHandle) Socket() Closeable {
+	return h.socket
+}
+
+// Release drops the caller's reference to the underlying socket, closing it
+// once no other caller holds a reference. Calling Release more than once is
+// a no-op.
+func (h *PortHandle) Release() error {
+	h.mu.Lock()
+	if h.released {
+		h.mu.Unlock()
+		return nil
+	}
+	h.released = true
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	h.mu.Unlock()
+	return h.allocator.release(h.key)
+}
+
+// Renew moves a leased PortHandle's auto-release deadline to ttl from now.
+// It is a no-op on a handle obtained via Open rather than Lease, or on one
+// that has already been released.
+//
+// Renew can't just Reset the existing timer: once a time.AfterFunc has
+// fired, its goroutine is already running (or queued) and Reset doesn't
+// stop that firing, only schedules an additional future one. So a handle
+// renewed right around its original deadline could still be released by
+// the stale firing, which would then Stop the freshly-armed timer too and
+// silently drop the lease. Instead, every Renew bumps a generation counter
+// and arms a brand new timer tagged with it; a firing only releases the
+// handle if its tagged generation still matches, so a stale firing is a
+// guaranteed no-op.
+func (h *PortHandle) Renew(ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.released || h.timer == nil {
+		return
+	}
+	h.timer.Stop()
+	h.generation++
+	h.armLocked(ttl)
+}
+
+// armLocked schedules auto-release after ttl, tagged with the handle's
+// current generation. Callers must hold h.mu.
+func (h *PortHandle) armLocked(ttl time.Duration) {
+	gen := h.generation
+	h.timer = time.AfterFunc(ttl, func() { h.fire(gen) })
+}
+
+// fire runs when a lease's timer expires. It releases the handle only if
+// gen is still the handle's current generation, i.e. no Renew has
+// superseded this timer since it was armed.
+func (h *PortHandle) fire(gen int64) {
+	h.mu.Lock()
+	if h.released || gen != h.generation {
+		h.mu.Unlock()
+		return
+	}
+	h.released = true
+	h.mu.Unlock()
+	h.allocator.release(h.key)
+}
+
+// portEntry is the shared state behind every PortHandle referencing the
+// same underlying socket.
+type portEntry struct {
+	socket   Closeable
+	refCount int
+}
+
+// PortAllocator deduplicates concurrent reservations of the same LocalPort:
+// callers requesting the same (IP, Port, Protocol, IPFamily) get distinct,
+// independently Release()-able handles that share a single underlying
+// socket, which is closed only once every handle has been released. This is
+// the pattern kube-proxy, OVN-Kubernetes and kubelet each re-implement
+// around PortOpener.
+type PortAllocator struct {
+	opener PortOpener
+
+	mu      sync.Mutex
+	entries map[string]*portEntry
+	metrics PortAllocatorMetrics
+}
+
+// Metrics returns a snapshot of the allocator's activity counters. Safe to
+// call concurrently with Open/Lease/Release.
+func (a *PortAllocator) Metrics() PortAllocatorMetrics {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.metrics
+}
+
+// NewPortAllocator returns a PortAllocator that opens new sockets via opener.
+func NewPortAllocator(opener PortOpener) *PortAllocator {
+	return &PortAllocator{opener: opener, entries: make(map[string]*portEntry)}
+}
+
+// Open returns a PortHandle referencing lp's underlying socket, opening it
+// if no other caller currently holds it.
+func (a *PortAllocator) Open(lp *LocalPort) (*PortHandle, error) {
+	return a.open(lp, 0)
+}
+
+// Lease behaves like Open, but the returned PortHandle is automatically
+// released after ttl unless Renew is called first.
+func (a *PortAllocator) Lease(lp *LocalPort, ttl time.Duration) (*PortHandle, error) {
+	return a.open(lp, ttl)
+}
+
+func (a *PortAllocator) open(lp *LocalPort, ttl time.Duration) (*PortHandle, error) {
+	key := portKey(lp)
+
+	a.mu.Lock()
+	entry, ok := a.entries[key]
+	if ok {
+		entry.refCount++
+		a.metrics.Contention++
+		a.mu.Unlock()
+	} else {
+		a.mu.Unlock()
+		socket, err := a.opener.OpenLocalPort(lp)
+		if err != nil {
+			a.mu.Lock()
+			a.metrics.BindFailures++
+			a.mu.Unlock()
+			return nil, err
+		}
+		a.mu.Lock()
+		if existing, raced := a.entries[key]; raced {
+			// Lost a race with another Open/Lease call; keep their socket
+			// and close the one this call just opened.
+			existing.refCount++
+			a.metrics.Contention++
+			a.mu.Unlock()
+			socket.Close()
+			entry = existing
+		} else {
+			entry = &portEntry{socket: socket, refCount: 1}
+			a.entries[key] = entry
+			a.metrics.OpenCount++
+			a.mu.Unlock()
+		}
+	}
+
+	handle := &PortHandle{allocator: a, key: key, socket: entry.socket}
+	if ttl > 0 {
+		handle.mu.Lock()
+		handle.armLocked(ttl)
+		handle.mu.Unlock()
+	}
+	return handle, nil
+}
+
+func (a *PortAllocator) release(key string) error {
+	a.mu.Lock()
+	entry, ok := a.entries[key]
+	if !ok {
+		a.mu.Unlock()
+		return nil
+	}
+	entry.refCount--
+	if entry.refCount > 0 {
+		a.mu.Unlock()
+		return nil
+	}
+	delete(a.entries, key)
+	a.mu.Unlock()
+	return entry.socket.Close()
+}
+
+// portKey returns the identity a PortAllocator dedupes reservations on.
+// Description is deliberately excluded, since two callers describing the
+// same (IP, Port, Protocol, IPFamily) differently still want to share it.
+func portKey(lp *LocalPort) string {
+	return fmt.Sprintf("%s/%s%s/%d", lp.IP, lp.Protocol, lp.IPFamily, lp.Port)
+}
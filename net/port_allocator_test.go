@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSocket's closed field is written by the allocator/handle's own
+// goroutine (e.g. a lease's timer) and read by the test goroutine, so it
+// needs to be an atomic.Bool rather than a plain bool to stay race-free
+// under `go test -race`.
+type fakeSocket struct {
+	closed atomic.Bool
+}
+
+func (f *fakeSocket) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+type fakeOpener struct {
+	opens int
+}
+
+func (f *fakeOpener) OpenLocalPort(lp *LocalPort) (Closeable, error) {
+	f.opens++
+	return &fakeSocket{}, nil
+}
+
+func TestPortAllocatorSharesAndClosesOnLastRelease(t *testing.T) {
+	opener := &fakeOpener{}
+	allocator := NewPortAllocator(opener)
+	lp, err := NewLocalPort("test", "1.2.3.4", "", 80, "tcp")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	h1, err := allocator.Open(lp)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	h2, err := allocator.Open(lp)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if opener.opens != 1 {
+		t.Errorf("expected 1 underlying open, got %d", opener.opens)
+	}
+	if m := allocator.Metrics(); m.OpenCount != 1 || m.Contention != 1 {
+		t.Errorf("unexpected metrics snapshot: %+v", m)
+	}
+	socket := h1.Socket().(*fakeSocket)
+
+	if err := h1.Release(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if socket.closed.Load() {
+		t.Errorf("socket closed while a handle was still outstanding")
+	}
+	if err := h2.Release(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !socket.closed.Load() {
+		t.Errorf("expected socket to be closed once every handle was released")
+	}
+	if opener.opens != 1 {
+		t.Errorf("expected only 1 underlying open across both handles, got %d", opener.opens)
+	}
+}
+
+func TestPortAllocatorLeaseAutoReleases(t *testing.T) {
+	opener := &fakeOpener{}
+	allocator := NewPortAllocator(opener)
+	lp, err := NewLocalPort("test", "1.2.3.4", "", 81, "tcp")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	h, err := allocator.Lease(lp, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	socket := h.Socket().(*fakeSocket)
+
+	if err := pollUntil(100*time.Millisecond, func() bool { return socket.closed.Load() }); err != nil {
+		t.Errorf("expected lease to auto-release and close the socket: %v", err)
+	}
+}
+
+func TestRenewPreventsAutoRelease(t *testing.T) {
+	opener := &fakeOpener{}
+	allocator := NewPortAllocator(opener)
+	lp, err := NewLocalPort("test", "1.2.3.4", "", 82, "tcp")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	h, err := allocator.Lease(lp, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	socket := h.Socket().(*fakeSocket)
+	defer h.Release()
+
+	deadline := time.Now().Add(60 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		h.Renew(5 * time.Millisecond)
+		if socket.closed.Load() {
+			t.Fatalf("socket closed despite continuous renewal")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func pollUntil(timeout time.Duration, cond func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return nil
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return errTimeout
+}
+
+var errTimeout = &timeoutError{}
+
+type timeoutError struct{}
+
+func (*timeoutError) Error() string { return "timed out waiting for condition" }
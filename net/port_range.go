@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// LocalPortRange represents a contiguous range of local ports along with a
+// protocol and potentially a specific IP family, analogous to LocalPort but
+// covering several consecutive ports in a single reservation.
+// A LocalPortRange can be opened and subsequently closed.
+type LocalPortRange struct {
+	// Description is an arbitrary string
+	Description string
+	// IP is the IP address part of the range.
+	// If this string is empty, the ports bind to all local IP addresses.
+	IP string
+	// If IPFamily is not empty, the ports bind only to addresses of this family
+	// IF empty along with IP, bind to local addresses of any family
+	IPFamily IPFamily
+	// StartPort is the first port number in the range
+	StartPort int
+	// Count is the number of consecutive ports in the range, starting at StartPort
+	Count int
+	// Protocol is the protocol, "tcp", "udp" or "sctp"
+	// The value is assumed to be lower-case
+	Protocol string
+}
+
+// NewLocalPortRange returns a LocalPortRange instance and ensures IPFamily and
+// IP are consistent, that the given protocol is valid and that Count is positive.
+func NewLocalPortRange(desc, ip string, ipFamily IPFamily, startPort, count int, protocol string) (*LocalPortRange, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("invalid port count %d", count)
+	}
+	// Reuse NewLocalPort's validation of protocol, ip and ipFamily by
+	// constructing (and discarding) a LocalPort for the first port in the range.
+	if _, err := NewLocalPort(desc, ip, ipFamily, startPort, protocol); err != nil {
+		return nil, err
+	}
+	return &LocalPortRange{Description: desc, IP: ip, IPFamily: ipFamily, StartPort: startPort, Count: count, Protocol: protocol}, nil
+}
+
+func (lpr *LocalPortRange) String() string {
+	ipPortRange := net.JoinHostPort(lpr.IP, fmt.Sprintf("%s-%s", strconv.Itoa(lpr.StartPort), strconv.Itoa(lpr.StartPort+lpr.Count-1)))
+	return fmt.Sprintf("%q (%s/%s%s)", lpr.Description, ipPortRange, lpr.Protocol, lpr.IPFamily)
+}
+
+// RangePortOpener can open a LocalPortRange and allows later closing it.
+// Abstracted out for testing.
+type RangePortOpener interface {
+	OpenLocalPortRange(lpr *LocalPortRange) ([]Closeable, error)
+}
+
+// listenPortRangeOpener opens port ranges by calling bind() and listen()
+// on each port in turn.
+type listenPortRangeOpener struct{}
+
+// OpenLocalPortRange holds the given range of local ports open.
+func (l *listenPortRangeOpener) OpenLocalPortRange(lpr *LocalPortRange) ([]Closeable, error) {
+	return openLocalPortRange(lpr)
+}
+
+func openLocalPortRange(lpr *LocalPortRange) ([]Closeable, error) {
+	sockets := make([]Closeable, 0, lpr.Count)
+	for port := lpr.StartPort; port < lpr.StartPort+lpr.Count; port++ {
+		lp, err := NewLocalPort(lpr.Description, lpr.IP, lpr.IPFamily, port, lpr.Protocol)
+		if err != nil {
+			closeAll(sockets)
+			return nil, err
+		}
+		socket, err := openLocalPort(lp)
+		if err != nil {
+			closeAll(sockets)
+			return nil, fmt.Errorf("can't open %s: %v", lp.String(), err)
+		}
+		sockets = append(sockets, socket)
+	}
+	return sockets, nil
+}
+
+// closeAll closes every non-nil socket in sockets, e.g. to roll back a
+// partially successful range reservation. Close errors are ignored since
+// the sockets are being discarded regardless.
+func closeAll(sockets []Closeable) {
+	for _, socket := range sockets {
+		if socket != nil {
+			socket.Close()
+		}
+	}
+}
+
+// OpenLocalPortRangeMultiProtocol reserves the same port range once per
+// protocol in protocols, a comma-separated list such as "tcp,udp", so that a
+// single PortMapping-style entry can be reserved with one call. If any
+// protocol fails to open, everything opened so far for this call is rolled back.
+func OpenLocalPortRangeMultiProtocol(desc, ip string, ipFamily IPFamily, startPort, count int, protocols string) ([]Closeable, error) {
+	var all []Closeable
+	for _, protocol := range strings.Split(protocols, ",") {
+		protocol = strings.TrimSpace(protocol)
+		lpr, err := NewLocalPortRange(desc, ip, ipFamily, startPort, count, protocol)
+		if err != nil {
+			closeAll(all)
+			return nil, err
+		}
+		sockets, err := openLocalPortRange(lpr)
+		if err != nil {
+			closeAll(all)
+			return nil, err
+		}
+		all = append(all, sockets...)
+	}
+	return all, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+// freePort returns a currently-unused TCP port on 127.0.0.1, suitable as
+// the base of a test range.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err finding a free port: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestLocalPortRangeString(t *testing.T) {
+	testCases := []struct {
+		description string
+		ip          string
+		family      IPFamily
+		startPort   int
+		count       int
+		protocol    string
+		expectedStr string
+		expectedErr bool
+	}{
+		{"IPv4 TCP range", "1.2.3.4", "", 30000, 3, "tcp", `"IPv4 TCP range" (1.2.3.4:30000-30002/tcp)`, false},
+		{"IPv6 UDP range, all addresses", "", IPv6, 8000, 1, "udp", `"IPv6 UDP range, all addresses" (:8000-8000/udp6)`, false},
+		{"Unsupported protocol", "1.2.3.4", "", 30000, 3, "http", "", true},
+		{"Invalid count", "1.2.3.4", "", 30000, 0, "tcp", "", true},
+	}
+
+	for _, tc := range testCases {
+		lpr, err := NewLocalPortRange(tc.description, tc.ip, tc.family, tc.startPort, tc.count, tc.protocol)
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("Expected err when creating LocalPortRange %v", tc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected err when creating LocalPortRange %s", err)
+			continue
+		}
+		str := lpr.String()
+		if str != tc.expectedStr {
+			t.Errorf("Unexpected output for %s, expected: %s, got: %s", tc.description, tc.expectedStr, str)
+		}
+	}
+}
+
+func TestOpenLocalPortRangeRollsBackOnFailure(t *testing.T) {
+	startPort := freePort(t)
+
+	// Occupy the middle port of the range so the reservation fails partway through.
+	blocked, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", startPort+1))
+	if err != nil {
+		t.Fatalf("unexpected err occupying port %d: %v", startPort+1, err)
+	}
+	defer blocked.Close()
+
+	lpr, err := NewLocalPortRange("test", "127.0.0.1", "", startPort, 3, "tcp")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := openLocalPortRange(lpr); err == nil {
+		t.Fatalf("expected an error reserving a range with an already-bound port")
+	}
+
+	// The already-opened earlier port should have been rolled back and be
+	// bindable again.
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", startPort))
+	if err != nil {
+		t.Errorf("expected port %d to have been released on rollback: %v", startPort, err)
+	} else {
+		ln.Close()
+	}
+}
+
+func TestOpenLocalPortRangeMultiProtocol(t *testing.T) {
+	startPort := freePort(t)
+
+	sockets, err := OpenLocalPortRangeMultiProtocol("test", "127.0.0.1", "", startPort, 2, "tcp,udp")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer closeAll(sockets)
+	if len(sockets) != 4 {
+		t.Errorf("expected 4 sockets (2 ports x 2 protocols), got %d", len(sockets))
+	}
+}
+
+func TestOpenLocalPortRangeMultiProtocolRollsBackOnFailure(t *testing.T) {
+	startPort := freePort(t)
+
+	// Occupy the udp port so the udp leg fails after the tcp leg already succeeded.
+	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("127.0.0.1:%d", startPort))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	blocked, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		t.Fatalf("unexpected err occupying udp port %d: %v", startPort, err)
+	}
+	defer blocked.Close()
+
+	if _, err := OpenLocalPortRangeMultiProtocol("test", "127.0.0.1", "", startPort, 1, "tcp,udp"); err == nil {
+		t.Fatalf("expected an error reserving a range with an already-bound udp port")
+	}
+
+	// The tcp leg, opened before the udp leg failed, should have been rolled back.
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", startPort))
+	if err != nil {
+		t.Errorf("expected tcp port %d to have been released on rollback: %v", startPort, err)
+	} else {
+		ln.Close()
+	}
+}
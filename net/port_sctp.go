@@ -0,0 +1,57 @@
+//go:build sctp
+
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ishidawataru/sctp"
+)
+
+// openSCTPPort reserves a real SCTP port by calling sctp.ListenSCTP. It is
+// only compiled in when this package is built with "-tags sctp", since
+// loading the sctp kernel module is a behavior change callers must opt
+// into explicitly (see openSCTPPort in port_sctp_stub.go for the default).
+func openSCTPPort(lp *LocalPort) (Closeable, error) {
+	network := "sctp" + string(lp.IPFamily)
+	addr := &sctp.SCTPAddr{Port: lp.Port}
+	if lp.IP != "" {
+		ip := net.ParseIP(lp.IP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip address %s", lp.IP)
+		}
+		addr.IPAddrs = []net.IPAddr{{IP: ip}}
+	}
+	listener, err := sctp.ListenSCTP(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return listener, nil
+}
+
+// probeSCTP reports whether this host can bind an SCTP loopback listener.
+func probeSCTP() bool {
+	listener, err := sctp.ListenSCTP("sctp", &sctp.SCTPAddr{IPAddrs: []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}})
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
@@ -0,0 +1,35 @@
+//go:build !sctp
+
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+// openSCTPPort is the default implementation used when this package is
+// built without the "sctp" build tag. SCTP ports are intentionally
+// ignored, to ensure we don't cause the sctp kernel module to be loaded,
+// which breaks userspace SCTP support (and may be considered a security
+// risk by some administrators). Build with "-tags sctp" to reserve real
+// SCTP ports via openSCTPPort in port_sctp.go.
+func openSCTPPort(lp *LocalPort) (Closeable, error) {
+	return nil, nil
+}
+
+// probeSCTP always reports false in the default build, since it never
+// attempts to load the sctp kernel module.
+func probeSCTP() bool {
+	return false
+}
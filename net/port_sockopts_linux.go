@@ -0,0 +1,58 @@
+//go:build linux
+
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenConfigForOptions returns a net.ListenConfig whose Control callback
+// applies opts via setsockopt before bind(). A nil opts returns the zero
+// value net.ListenConfig, i.e. today's default behavior.
+func listenConfigForOptions(opts *SocketOptions) (net.ListenConfig, error) {
+	if opts == nil {
+		return net.ListenConfig{}, nil
+	}
+	return net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var setErr error
+			err := c.Control(func(fd uintptr) {
+				if opts.ReuseAddr {
+					setErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+				}
+				if setErr == nil && opts.ReusePort {
+					setErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+				}
+				if setErr == nil && opts.IPFreeBind {
+					setErr = unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_FREEBIND, 1)
+				}
+				if setErr == nil && opts.IPTransparent {
+					setErr = unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_TRANSPARENT, 1)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return setErr
+		},
+	}, nil
+}
@@ -0,0 +1,34 @@
+//go:build !linux
+
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"fmt"
+	"net"
+)
+
+// listenConfigForOptions returns a net.ListenConfig honoring opts. On
+// non-Linux platforms SocketOptions aren't implemented, so a non-nil opts
+// is rejected rather than silently ignored.
+func listenConfigForOptions(opts *SocketOptions) (net.ListenConfig, error) {
+	if opts != nil {
+		return net.ListenConfig{}, fmt.Errorf("SocketOptions are not supported on this platform")
+	}
+	return net.ListenConfig{}, nil
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import "testing"
+
+func TestListenConfigForOptions(t *testing.T) {
+	lc, err := listenConfigForOptions(nil)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if lc.Control != nil {
+		t.Errorf("expected a nil Control when opts is nil")
+	}
+
+	if _, err := listenConfigForOptions(&SocketOptions{ReuseAddr: true}); err == nil {
+		t.Errorf("expected an error requesting SocketOptions on an unsupported platform")
+	}
+}
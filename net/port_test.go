@@ -18,6 +18,46 @@ package net
 
 import "testing"
 
+type closeOnly struct{}
+
+func (closeOnly) Close() error { return nil }
+
+func TestAsFileCloseable(t *testing.T) {
+	lp, err := NewLocalPort("test", "127.0.0.1", IPv4, 0, "tcp")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	socket, err := openLocalPort(lp)
+	if err != nil {
+		t.Fatalf("unexpected err opening port: %v", err)
+	}
+	defer socket.Close()
+
+	fc, ok := AsFileCloseable(socket)
+	if !ok {
+		t.Fatalf("expected the TCP listener from openLocalPort to be a FileCloseable")
+	}
+	f, err := fc.File()
+	if err != nil {
+		t.Fatalf("unexpected err from File(): %v", err)
+	}
+	f.Close()
+
+	if _, ok := AsFileCloseable(closeOnly{}); ok {
+		t.Errorf("expected a Closeable with no File() method to not be a FileCloseable")
+	}
+}
+
+func TestOpenLocalPortRejectsSocketOptionsForSCTP(t *testing.T) {
+	lp, err := NewLocalPortWithOptions("test", "127.0.0.1", IPv4, 0, "sctp", &SocketOptions{ReuseAddr: true})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := openLocalPort(lp); err == nil {
+		t.Errorf("expected an error requesting SocketOptions on sctp")
+	}
+}
+
 func TestLocalPortString(t *testing.T) {
 	testCases := []struct {
 		description string
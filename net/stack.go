@@ -0,0 +1,72 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"net"
+	"sync"
+)
+
+var (
+	ipv4Once      sync.Once
+	ipv4Supported bool
+
+	ipv6Once      sync.Once
+	ipv6Supported bool
+
+	sctpOnce      sync.Once
+	sctpSupported bool
+)
+
+// SupportsIPv4 reports whether this host can bind an IPv4 loopback listener.
+// The underlying probe runs at most once per process; the result is cached.
+func SupportsIPv4() bool {
+	ipv4Once.Do(func() {
+		ipv4Supported = probeListen("tcp4", "127.0.0.1:0")
+	})
+	return ipv4Supported
+}
+
+// SupportsIPv6 reports whether this host can bind an IPv6 loopback listener.
+// The underlying probe runs at most once per process; the result is cached.
+func SupportsIPv6() bool {
+	ipv6Once.Do(func() {
+		ipv6Supported = probeListen("tcp6", "[::1]:0")
+	})
+	return ipv6Supported
+}
+
+// SupportsSCTP reports whether this host can bind an SCTP loopback listener.
+// It is only ever true when this package is built with "-tags sctp"; the
+// default build never loads the sctp kernel module, so it always reports
+// false. The underlying probe runs at most once per process; the result is
+// cached.
+func SupportsSCTP() bool {
+	sctpOnce.Do(func() {
+		sctpSupported = probeSCTP()
+	})
+	return sctpSupported
+}
+
+func probeListen(network, address string) bool {
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import "testing"
+
+func TestPreferredIPFamily(t *testing.T) {
+	wantUnbound := IPFamily("")
+	if !SupportsIPv4() && SupportsIPv6() {
+		wantUnbound = IPv6
+	}
+
+	testCases := []struct {
+		description string
+		lp          *LocalPort
+		expected    IPFamily
+	}{
+		{"explicit IPv6 family is left alone", &LocalPort{IPFamily: IPv6, Protocol: "tcp"}, IPv6},
+		{"explicit IP pins the family", &LocalPort{IP: "1.2.3.4", Protocol: "tcp"}, ""},
+		{"sctp is left alone", &LocalPort{Protocol: "sctp"}, ""},
+		{"unbound tcp picks a family based on host support", &LocalPort{Protocol: "tcp"}, wantUnbound},
+	}
+
+	for _, tc := range testCases {
+		if got := preferredIPFamily(tc.lp); got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.description, tc.expected, got)
+		}
+	}
+}
+
+func TestSupportsIPv6(t *testing.T) {
+	got := SupportsIPv6()
+	want := probeListen("tcp6", "[::1]:0")
+	if got != want {
+		t.Errorf("SupportsIPv6() = %v, but a fresh probe reports %v", got, want)
+	}
+}
+
+func TestSupportsSCTP(t *testing.T) {
+	got := SupportsSCTP()
+	want := probeSCTP()
+	if got != want {
+		t.Errorf("SupportsSCTP() = %v, but a fresh probe reports %v", got, want)
+	}
+}
+
+func TestNewLocalPortStrict(t *testing.T) {
+	testCases := []struct {
+		description string
+		strict      bool
+		ipFamily    IPFamily
+		expectErr   bool
+	}{
+		{"non-strict never rejects", false, IPv6, false},
+		{"strict IPv4 succeeds when IPv4 is supported", true, IPv4, !SupportsIPv4()},
+		{"strict with no family never rejects", true, "", false},
+	}
+
+	for _, tc := range testCases {
+		_, err := NewLocalPortStrict(tc.strict, "test", "", tc.ipFamily, 0, "tcp")
+		if tc.expectErr && err == nil {
+			t.Errorf("%s: expected an error", tc.description)
+		}
+		if !tc.expectErr && err != nil {
+			t.Errorf("%s: unexpected err: %v", tc.description, err)
+		}
+	}
+}